@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+)
+
+const (
+	defaultArgonTime    = 4
+	defaultArgonMemory  = 64 * 1024 // KiB
+	defaultArgonThreads = 4
+
+	derivedKeySize = 32 // AES-256
+)
+
+// deriveKey runs Argon2id over passphrase and salt, producing a 32-byte key
+// suitable for AES-256-GCM.
+func deriveKey(passphrase string, salt []byte, time, memoryKiB uint32, threads byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, time, memoryKiB, threads, derivedKeySize)
+}
+
+// newSalt returns a fresh random salt for a new file header.
+func newSalt() ([saltSize]byte, error) {
+	var salt [saltSize]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return salt, fmt.Errorf("generating salt: %w", err)
+	}
+	return salt, nil
+}
+
+// readPassphrase resolves the passphrase to use for this run. It checks the
+// PASSPHRASE environment variable first, then falls back to an interactive
+// prompt (read via golang.org/x/term so the passphrase is never echoed) when
+// prompt is true. The passphrase is deliberately never accepted as a CLI flag
+// since that would leak it into shell history and the process list.
+func readPassphrase(prompt bool) (string, error) {
+	if p := os.Getenv("PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	if !prompt {
+		return "", fmt.Errorf("no passphrase provided: set PASSPHRASE or pass -P to be prompted")
+	}
+
+	fmt.Fprint(os.Stderr, "Enter passphrase: ")
+	b, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kabeer11000/university-monorepo/pkg/streamcrypt"
+)
+
+// manifestSuffix names the sibling file ProcessDirectory writes during
+// directory encryption and consults during directory verification.
+const manifestSuffix = ".manifest.enc"
+
+// manifestEntry records what a directory's manifest needs to remember about
+// one encrypted file so -verify can later confirm it is still intact.
+type manifestEntry struct {
+	RelPath       string `json:"relpath"`
+	PlaintextHash string `json:"plaintext_blake2b"`
+	CipherSize    int64  `json:"ciphertext_size"`
+}
+
+// manifestBuilder collects manifestEntry values from the concurrent
+// ProcessFile goroutines ProcessDirectory spawns during encryption.
+type manifestBuilder struct {
+	mu      sync.Mutex
+	entries []manifestEntry
+}
+
+func (b *manifestBuilder) add(entry manifestEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, entry)
+}
+
+// verifyReport collects per-file verification outcomes from the concurrent
+// ProcessFile goroutines ProcessDirectory spawns during verification.
+type verifyReport struct {
+	mu     sync.Mutex
+	ok     int
+	failed []string
+}
+
+func (r *verifyReport) pass() {
+	r.mu.Lock()
+	r.ok++
+	r.mu.Unlock()
+}
+
+func (r *verifyReport) fail(relPath string, reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, fmt.Sprintf("%s: %v", relPath, reason))
+}
+
+// summarize prints the verification results and reports whether every file
+// checked out, so the caller knows whether to exit non-zero.
+func (r *verifyReport) summarize() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Printf("Verified %d file(s), %d failure(s)\n", r.ok, len(r.failed))
+	for _, f := range r.failed {
+		fmt.Println("  FAILED:", f)
+	}
+	return len(r.failed) == 0
+}
+
+// writeManifest encrypts entries into directoryPath+manifestSuffix using a
+// freshly-derived key, the same native header+stream format used for file
+// contents, so it authenticates the same way a processed file would.
+func writeManifest(passphrase string, argon argonParams, directoryPath string, entries []manifestEntry) error {
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+
+	salt, err := newSalt()
+	if err != nil {
+		return fmt.Errorf("generating manifest salt: %w", err)
+	}
+	key := deriveKey(passphrase, salt[:], argon.time, argon.memoryKiB, argon.threads)
+
+	manifestFile, err := os.Create(directoryPath + manifestSuffix)
+	if err != nil {
+		return fmt.Errorf("creating manifest file: %w", err)
+	}
+	defer manifestFile.Close()
+
+	header := &fileHeader{
+		KDFID:   kdfArgon2id,
+		Time:    argon.time,
+		Memory:  argon.memoryKiB,
+		Threads: argon.threads,
+		Salt:    salt,
+	}
+	if err := writeHeader(manifestFile, header); err != nil {
+		return fmt.Errorf("writing manifest header: %w", err)
+	}
+
+	return streamcrypt.EncryptStream(key, bytes.NewReader(plaintext), manifestFile, streamcrypt.StreamOpts{})
+}
+
+// readManifest decrypts and parses directoryPath+manifestSuffix, returning
+// its entries indexed by relative path for -verify to look up against.
+func readManifest(passphrase string, directoryPath string) (map[string]manifestEntry, error) {
+	manifestFile, err := os.Open(directoryPath + manifestSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("opening manifest file: %w", err)
+	}
+	defer manifestFile.Close()
+
+	header, err := readHeader(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest header: %w", err)
+	}
+	key := deriveKey(passphrase, header.Salt[:], header.Time, header.Memory, header.Threads)
+
+	var plaintext bytes.Buffer
+	if err := streamcrypt.DecryptStream(key, manifestFile, &plaintext, streamcrypt.StreamOpts{ReedSolomon: header.ReedSolomon}); err != nil {
+		return nil, fmt.Errorf("decrypting manifest: %w", err)
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal(plaintext.Bytes(), &entries); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	byPath := make(map[string]manifestEntry, len(entries))
+	for _, entry := range entries {
+		byPath[entry.RelPath] = entry
+	}
+	return byPath, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rfjakob/eme"
+	"golang.org/x/crypto/hkdf"
+)
+
+// nameKeyFile is the per-directory sidecar rclone-crypt style name
+// encryption borrows its salt from. The root directory's sidecar additionally
+// carries the Argon2id salt and cost parameters the whole tree's name key was
+// derived from, so decrypt can reconstruct it from the passphrase alone.
+const nameKeyFile = ".namekey"
+
+const dirSaltSize = 16
+
+var nameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// deriveDirNameKey expands treeKey into a directory-specific AES-256 key
+// using HKDF-SHA256, with dirSalt (from that directory's .namekey) as salt
+// and a fixed "name" info string, so sibling directories never share a key
+// even though they all derive from the same treeKey.
+func deriveDirNameKey(treeKey, dirSalt []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, treeKey, dirSalt, []byte("name")), key); err != nil {
+		return nil, fmt.Errorf("deriving directory name key: %w", err)
+	}
+	return key, nil
+}
+
+// encryptNameComponent deterministically encrypts a single path component
+// with AES-EME under key, so the same name always maps to the same
+// ciphertext within a directory (required for the mapping to be
+// reversible without an index).
+func encryptNameComponent(key []byte, name string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("creating name cipher: %w", err)
+	}
+	padded := pkcs7Pad([]byte(name), aes.BlockSize)
+	ciphertext := eme.New(block).Encrypt(make([]byte, aes.BlockSize), padded)
+	return strings.ToLower(nameEncoding.EncodeToString(ciphertext)), nil
+}
+
+// decryptNameComponent reverses encryptNameComponent.
+func decryptNameComponent(key []byte, encoded string) (string, error) {
+	ciphertext, err := nameEncoding.DecodeString(strings.ToUpper(encoded))
+	if err != nil {
+		return "", fmt.Errorf("base32-decoding name: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("creating name cipher: %w", err)
+	}
+	padded := eme.New(block).Decrypt(make([]byte, aes.BlockSize), ciphertext)
+	name, err := pkcs7Unpad(padded)
+	if err != nil {
+		return "", fmt.Errorf("unpadding name: %w", err)
+	}
+	return string(name), nil
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	pad := blockSize - len(data)%blockSize
+	padded := make([]byte, len(data)+pad)
+	copy(padded, data)
+	for i := len(data); i < len(padded); i++ {
+		padded[i] = byte(pad)
+	}
+	return padded
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty padded data")
+	}
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > len(data) {
+		return nil, fmt.Errorf("invalid PKCS7 padding")
+	}
+	return data[:len(data)-pad], nil
+}
+
+// rootNameKeyHeaderSize is the size of the extra fields only the root
+// .namekey carries: the Argon2id salt and cost parameters needed to
+// re-derive the tree name key from the passphrase alone.
+const rootNameKeyHeaderSize = dirSaltSize + 4 + 4 + 1
+
+// writeNameKeySidecar creates the .namekey file for dir. treeParams is only
+// non-nil for the tree root, which additionally has to record how to
+// re-derive the tree name key from the passphrase.
+func writeNameKeySidecar(dir string, treeParams *nameTreeParams, dirSalt []byte) error {
+	var data []byte
+	if treeParams != nil {
+		data = append(data, treeParams.argonSalt...)
+		data = binary.BigEndian.AppendUint32(data, treeParams.argon.time)
+		data = binary.BigEndian.AppendUint32(data, treeParams.argon.memoryKiB)
+		data = append(data, treeParams.argon.threads)
+	}
+	data = append(data, dirSalt...)
+	return os.WriteFile(filepath.Join(dir, nameKeyFile), data, 0600)
+}
+
+// nameTreeParams is what the root .namekey records about how the tree name
+// key was derived, so decrypt can reproduce the exact same key.
+type nameTreeParams struct {
+	argonSalt []byte
+	argon     argonParams
+}
+
+// readNameKeySidecar reads dir's .namekey. root indicates whether dir is
+// the tree root, in which case the leading bytes are the tree's Argon2id
+// parameters rather than part of the directory salt.
+func readNameKeySidecar(dir string, root bool) (treeParams *nameTreeParams, dirSalt []byte, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, nameKeyFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", nameKeyFile, err)
+	}
+	if root {
+		if len(data) != rootNameKeyHeaderSize+dirSaltSize {
+			return nil, nil, fmt.Errorf("malformed root %s", nameKeyFile)
+		}
+		treeParams = &nameTreeParams{
+			argonSalt: data[:dirSaltSize],
+			argon: argonParams{
+				time:      binary.BigEndian.Uint32(data[dirSaltSize:]),
+				memoryKiB: binary.BigEndian.Uint32(data[dirSaltSize+4:]),
+				threads:   data[dirSaltSize+8],
+			},
+		}
+		return treeParams, data[rootNameKeyHeaderSize:], nil
+	}
+	if len(data) != dirSaltSize {
+		return nil, nil, fmt.Errorf("malformed %s", nameKeyFile)
+	}
+	return nil, data, nil
+}
+
+// hasNameKeySidecar reports whether dir looks like the root of a tree whose
+// names were encrypted with -encrypt-names, so decrypt can detect the mode
+// without being told about it explicitly.
+func hasNameKeySidecar(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, nameKeyFile))
+	return err == nil
+}
+
+// EncryptDirectoryNames walks root bottom-up and deterministically renames
+// every file and subdirectory to an opaque, EME-encrypted name, leaving a
+// .namekey sidecar in every directory so the mapping can be reversed. It
+// must run after file contents have already been encrypted in place, since
+// it renames whatever it finds.
+func EncryptDirectoryNames(passphrase string, argonOpts argonParams, root string) error {
+	argonSalt, err := newSalt()
+	if err != nil {
+		return fmt.Errorf("generating name tree salt: %w", err)
+	}
+	treeKey := deriveKey(passphrase, argonSalt[:], argonOpts.time, argonOpts.memoryKiB, argonOpts.threads)
+
+	treeParams := &nameTreeParams{argonSalt: argonSalt[:], argon: argonOpts}
+	_, err = encryptNamesInDir(treeKey, root, treeParams)
+	return err
+}
+
+// encryptNamesInDir recurses into dir, encrypts its own entries' names, and
+// returns the path to dir itself, which it leaves under its original name so
+// the caller higher up the tree can rename it. treeParams is only non-nil for
+// the tree root, which alone needs to record how to re-derive treeKey.
+func encryptNamesInDir(treeKey []byte, dir string, treeParams *nameTreeParams) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+
+	var dirSalt [dirSaltSize]byte
+	if _, err := rand.Read(dirSalt[:]); err != nil {
+		return "", fmt.Errorf("generating directory salt: %w", err)
+	}
+	dirKey, err := deriveDirNameKey(treeKey, dirSalt[:])
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == nameKeyFile {
+			continue
+		}
+		path := filepath.Join(dir, name)
+
+		if entry.IsDir() {
+			newPath, err := encryptNamesInDir(treeKey, path, nil)
+			if err != nil {
+				return "", err
+			}
+			name = filepath.Base(newPath)
+			path = newPath
+		}
+
+		encryptedName, err := encryptNameComponent(dirKey, name)
+		if err != nil {
+			return "", err
+		}
+		newPath := filepath.Join(dir, encryptedName)
+		if err := os.Rename(path, newPath); err != nil {
+			return "", fmt.Errorf("renaming %s: %w", path, err)
+		}
+	}
+
+	if err := writeNameKeySidecar(dir, treeParams, dirSalt[:]); err != nil {
+		return "", fmt.Errorf("writing name key sidecar for %s: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+// DecryptDirectoryNames is the inverse of EncryptDirectoryNames: it walks
+// root top-down, restoring every directory and file to its original name
+// using the .namekey sidecars, and removes the sidecars as it goes.
+func DecryptDirectoryNames(passphrase string, root string) error {
+	treeParams, rootDirSalt, err := readNameKeySidecar(root, true)
+	if err != nil {
+		return err
+	}
+	treeKey := deriveKey(passphrase, treeParams.argonSalt, treeParams.argon.time, treeParams.argon.memoryKiB, treeParams.argon.threads)
+
+	return decryptNamesInDir(treeKey, root, rootDirSalt)
+}
+
+func decryptNamesInDir(treeKey []byte, dir string, dirSalt []byte) error {
+	dirKey, err := deriveDirNameKey(treeKey, dirSalt)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+	// Sort so renames are deterministic and easy to reason about; order
+	// doesn't otherwise matter since every entry's new name is independent.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == nameKeyFile {
+			continue
+		}
+
+		originalName, err := decryptNameComponent(dirKey, name)
+		if err != nil {
+			return fmt.Errorf("decrypting name %s in %s: %w", name, dir, err)
+		}
+
+		oldPath := filepath.Join(dir, name)
+		newPath := filepath.Join(dir, originalName)
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return fmt.Errorf("renaming %s: %w", oldPath, err)
+		}
+
+		if entry.IsDir() {
+			_, childDirSalt, err := readNameKeySidecar(newPath, false)
+			if err != nil {
+				return err
+			}
+			if err := decryptNamesInDir(treeKey, newPath, childDirSalt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.Remove(filepath.Join(dir, nameKeyFile))
+}
@@ -1,34 +1,61 @@
 package main
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/kabeer11000/university-monorepo/pkg/streamcrypt"
 )
 
-// PadKey ensures the key is of a valid length for AES (16, 24, or 32 bytes)
-func PadKey(key *string) {
-	keyLen := len(*key)
-	if keyLen < 16 {
-		*key = *key + strings.Repeat("0", 16-keyLen)
-	} else if keyLen < 24 {
-		*key = *key + strings.Repeat("0", 24-keyLen)
-	} else if keyLen < 32 {
-		*key = *key + strings.Repeat("0", 32-keyLen)
-	} else if keyLen > 32 {
-		*key = (*key)[:32]
-	}
+// formatNative is this tool's own versioned header + chunked AEAD format.
+// formatAge defers to filippo.io/age so files interoperate with age/rage.
+const (
+	formatNative = "native"
+	formatAge    = "age"
+)
+
+// processOpts bundles the run's tunable behaviour so it can travel through
+// ProcessDirectory/ProcessFile without an ever-growing positional argument
+// list as new flags are added.
+type processOpts struct {
+	argon        argonParams
+	deleteOrig   bool
+	shred        bool
+	shredPasses  int
+	reedSolomon  bool
+	format       string
+	recipients   []string
+	identityFile string
+	encryptNames bool
+
+	// verify runs the decrypt/authentication pipeline but discards the
+	// plaintext instead of writing it out.
+	verify bool
+
+	// manifestRoot is the directory ProcessFile's filePath is relative to,
+	// used to key manifestBuilder/manifestEntries by relative path.
+	manifestRoot string
+	// manifestBuilder, when set, collects a manifestEntry for every file
+	// ProcessFile encrypts (directory encryption only).
+	manifestBuilder *manifestBuilder
+	// manifestEntries, when set, is what -verify checks each decrypted
+	// file's recomputed hash against (directory verification only).
+	manifestEntries map[string]manifestEntry
+	// verifyReport collects pass/fail outcomes when manifestEntries is set.
+	verifyReport *verifyReport
 }
 
 // ProcessFile encrypts or decrypts the given file based on the provided flags
-func ProcessFile(key []byte, encrypt bool, deleteOriginal bool, filePath string, wg *sync.WaitGroup) {
+func ProcessFile(passphrase string, opts processOpts, encrypt bool, filePath string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	file, err := os.Open(filePath)
@@ -38,36 +65,8 @@ func ProcessFile(key []byte, encrypt bool, deleteOriginal bool, filePath string,
 	}
 	defer file.Close()
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		fmt.Println("Error creating cipher block:", err)
-		return
-	}
-
 	if encrypt {
 		fmt.Printf("Encrypting file: %s\n", filePath)
-		gcm, err := cipher.NewGCM(block)
-		if err != nil {
-			fmt.Println("Error creating GCM:", err)
-			return
-		}
-
-		nonce := make([]byte, gcm.NonceSize())
-		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-			fmt.Println("Error reading nonce:", err)
-			return
-		}
-
-		fileInfo, err := file.Stat()
-		if err != nil {
-			fmt.Println("Error stating file:", err)
-			return
-		}
-
-		fileData := make([]byte, fileInfo.Size())
-		file.Read(fileData)
-
-		encryptedData := gcm.Seal(nil, nonce, fileData, nil)
 
 		encryptedFilePath := filePath + ".enc"
 		encryptedFile, err := os.Create(encryptedFilePath)
@@ -77,12 +76,77 @@ func ProcessFile(key []byte, encrypt bool, deleteOriginal bool, filePath string,
 		}
 		defer encryptedFile.Close()
 
-		encryptedFile.Write(nonce)
-		encryptedFile.Write(encryptedData)
+		var plaintextHash hash.Hash
+		var plainIn io.Reader = file
+		if opts.manifestBuilder != nil {
+			plaintextHash, err = blake2b.New256(nil)
+			if err != nil {
+				fmt.Println("Error initializing manifest hash:", err)
+				return
+			}
+			plainIn = io.TeeReader(file, plaintextHash)
+		}
+
+		if opts.format == formatAge {
+			if err := encryptAge(opts.recipients, passphrase, plainIn, encryptedFile); err != nil {
+				fmt.Println("Error encrypting file:", err)
+				return
+			}
+		} else {
+			salt, err := newSalt()
+			if err != nil {
+				fmt.Println("Error generating salt:", err)
+				return
+			}
+			key := deriveKey(passphrase, salt[:], opts.argon.time, opts.argon.memoryKiB, opts.argon.threads)
+
+			header := &fileHeader{
+				KDFID:       kdfArgon2id,
+				Time:        opts.argon.time,
+				Memory:      opts.argon.memoryKiB,
+				Threads:     opts.argon.threads,
+				Salt:        salt,
+				ReedSolomon: opts.reedSolomon,
+			}
+			if err := writeHeader(encryptedFile, header); err != nil {
+				fmt.Println("Error writing file header:", err)
+				return
+			}
+
+			streamOpts := streamcrypt.StreamOpts{ReedSolomon: opts.reedSolomon}
+			if err := streamcrypt.EncryptStream(key, plainIn, encryptedFile, streamOpts); err != nil {
+				fmt.Println("Error encrypting file:", err)
+				return
+			}
+		}
 
 		fmt.Printf("File encrypted and saved as %s\n", encryptedFilePath)
 
-		if deleteOriginal {
+		if opts.manifestBuilder != nil {
+			relPath, err := filepath.Rel(opts.manifestRoot, filePath)
+			if err != nil {
+				fmt.Println("Error computing manifest path:", err)
+				return
+			}
+			cipherInfo, err := encryptedFile.Stat()
+			if err != nil {
+				fmt.Println("Error stating encrypted file for manifest:", err)
+				return
+			}
+			opts.manifestBuilder.add(manifestEntry{
+				RelPath:       relPath,
+				PlaintextHash: hex.EncodeToString(plaintextHash.Sum(nil)),
+				CipherSize:    cipherInfo.Size(),
+			})
+		}
+
+		if opts.shred {
+			if err := secureDelete(filePath, opts.shredPasses); err != nil {
+				fmt.Println("Error shredding original file:", err)
+			} else {
+				fmt.Printf("Original file %s shredded and deleted\n", filePath)
+			}
+		} else if opts.deleteOrig {
 			err := os.Remove(filePath)
 			if err != nil {
 				fmt.Println("Error deleting original file:", err)
@@ -91,46 +155,94 @@ func ProcessFile(key []byte, encrypt bool, deleteOriginal bool, filePath string,
 			}
 		}
 	} else {
-		fmt.Printf("Decrypting file: %s\n", filePath)
-		fileInfo, err := file.Stat()
-		if err != nil {
-			fmt.Println("Error stating file:", err)
-			return
+		decryptedFilePath := strings.TrimSuffix(filePath, ".enc")
+
+		var relPath string
+		if opts.verify && opts.manifestEntries != nil {
+			rel, err := filepath.Rel(opts.manifestRoot, decryptedFilePath)
+			if err != nil {
+				fmt.Println("Error computing manifest path:", err)
+				return
+			}
+			relPath = rel
 		}
 
-		fileData := make([]byte, fileInfo.Size())
-		file.Read(fileData)
+		var out io.Writer
+		var decryptedFile *os.File
+		var plaintextHash hash.Hash
+		if opts.verify {
+			fmt.Printf("Verifying file: %s\n", filePath)
+			if opts.manifestEntries != nil {
+				var err error
+				plaintextHash, err = blake2b.New256(nil)
+				if err != nil {
+					fmt.Println("Error initializing verify hash:", err)
+					return
+				}
+				out = io.MultiWriter(io.Discard, plaintextHash)
+			} else {
+				out = io.Discard
+			}
+		} else {
+			fmt.Printf("Decrypting file: %s\n", filePath)
+
+			var err error
+			decryptedFile, err = os.Create(decryptedFilePath)
+			if err != nil {
+				fmt.Println("Error creating decrypted file:", err)
+				return
+			}
+			defer decryptedFile.Close()
+			out = decryptedFile
+		}
 
-		gcm, err := cipher.NewGCM(block)
+		isAge, in, err := sniffAge(file)
 		if err != nil {
-			fmt.Println("Error creating GCM:", err)
+			fmt.Println("Error detecting file format:", err)
+			if opts.verify && opts.manifestEntries != nil {
+				opts.verifyReport.fail(relPath, err)
+			}
 			return
 		}
 
-		nonceSize := gcm.NonceSize()
-		nonce := fileData[:nonceSize]
-		encryptedData := fileData[nonceSize:]
+		if isAge {
+			err = decryptAge(opts.identityFile, passphrase, in, out)
+		} else {
+			var header *fileHeader
+			header, err = readHeader(in)
+			if err == nil {
+				key := deriveKey(passphrase, header.Salt[:], header.Time, header.Memory, header.Threads)
+				streamOpts := streamcrypt.StreamOpts{ReedSolomon: header.ReedSolomon}
+				err = streamcrypt.DecryptStream(key, in, out, streamOpts)
+			}
+		}
 
-		decryptedData, err := gcm.Open(nil, nonce, encryptedData, nil)
 		if err != nil {
 			fmt.Println("Error decrypting file:", err)
+			if opts.verify && opts.manifestEntries != nil {
+				opts.verifyReport.fail(relPath, err)
+			}
 			return
 		}
 
-		decryptedFilePath := strings.TrimSuffix(filePath, ".enc")
-		decryptedFile, err := os.Create(decryptedFilePath)
-		if err != nil {
-			fmt.Println("Error creating decrypted file:", err)
+		if opts.verify {
+			if opts.manifestEntries != nil {
+				entry, ok := opts.manifestEntries[relPath]
+				if !ok {
+					opts.verifyReport.fail(relPath, fmt.Errorf("no manifest entry for this file"))
+				} else if hex.EncodeToString(plaintextHash.Sum(nil)) != entry.PlaintextHash {
+					opts.verifyReport.fail(relPath, fmt.Errorf("plaintext hash mismatch"))
+				} else {
+					opts.verifyReport.pass()
+				}
+			}
+			fmt.Printf("File %s authenticated\n", filePath)
 			return
 		}
-		defer decryptedFile.Close()
-
-		decryptedFile.Write(decryptedData)
 
 		fmt.Printf("File decrypted and saved as %s\n", decryptedFilePath)
 
-		err = os.Remove(filePath)
-		if err != nil {
+		if err := os.Remove(filePath); err != nil {
 			fmt.Println("Error deleting encrypted file:", err)
 		} else {
 			fmt.Printf("Encrypted file %s deleted\n", filePath)
@@ -138,8 +250,46 @@ func ProcessFile(key []byte, encrypt bool, deleteOriginal bool, filePath string,
 	}
 }
 
-// ProcessDirectory recursively processes all files in the given directory
-func ProcessDirectory(key []byte, encrypt bool, deleteOriginal bool, directoryPath string) {
+// ProcessDirectory recursively processes all files in the given directory.
+// It returns an error if the directory couldn't be walked, or if -verify
+// found any file that failed to authenticate.
+func ProcessDirectory(passphrase string, opts processOpts, encrypt bool, directoryPath string) error {
+	// Clean first so a trailing slash (as bash tab-completion adds) can't
+	// make directoryPath+manifestSuffix land inside the directory instead
+	// of alongside it.
+	directoryPath = filepath.Clean(directoryPath)
+
+	if !encrypt && hasNameKeySidecar(directoryPath) {
+		fmt.Println("Restoring encrypted file names before decrypting contents")
+		if err := DecryptDirectoryNames(passphrase, directoryPath); err != nil {
+			return fmt.Errorf("decrypting file names: %w", err)
+		}
+		if opts.verify {
+			// -verify is supposed to be a safe, repeatable, read-only check,
+			// so it must not leave the tree permanently de-anonymized the
+			// way a real -decrypt does. Re-encrypt the names again once
+			// we're done, whether or not verification itself succeeded.
+			defer func() {
+				fmt.Println("Re-encrypting file names")
+				if err := EncryptDirectoryNames(passphrase, opts.argon, directoryPath); err != nil {
+					fmt.Println("Error re-encrypting file names after verify:", err)
+				}
+			}()
+		}
+	}
+
+	opts.manifestRoot = directoryPath
+	if encrypt {
+		opts.manifestBuilder = &manifestBuilder{}
+	} else if opts.verify {
+		entries, err := readManifest(passphrase, directoryPath)
+		if err != nil {
+			return fmt.Errorf("reading manifest: %w", err)
+		}
+		opts.manifestEntries = entries
+		opts.verifyReport = &verifyReport{}
+	}
+
 	var wg sync.WaitGroup
 
 	err := filepath.Walk(directoryPath, func(path string, info os.FileInfo, err error) error {
@@ -148,49 +298,108 @@ func ProcessDirectory(key []byte, encrypt bool, deleteOriginal bool, directoryPa
 		}
 		if !info.IsDir() {
 			wg.Add(1)
-			go ProcessFile(key, encrypt, deleteOriginal, path, &wg)
+			go ProcessFile(passphrase, opts, encrypt, path, &wg)
 		}
 		return nil
 	})
 
 	if err != nil {
-		fmt.Println("Error walking the directory:", err)
-		return
+		return fmt.Errorf("walking the directory: %w", err)
 	}
 
 	wg.Wait()
+
+	if encrypt {
+		if err := writeManifest(passphrase, opts.argon, directoryPath, opts.manifestBuilder.entries); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+		fmt.Printf("Manifest written to %s\n", directoryPath+manifestSuffix)
+
+		if opts.encryptNames {
+			fmt.Println("Encrypting file names")
+			if err := EncryptDirectoryNames(passphrase, opts.argon, directoryPath); err != nil {
+				return fmt.Errorf("encrypting file names: %w", err)
+			}
+		}
+	}
+
+	if opts.verify && !opts.verifyReport.summarize() {
+		return fmt.Errorf("verification failed for one or more files")
+	}
+
+	return nil
+}
+
+// argonParams bundles the tunable Argon2id cost parameters so they travel
+// together from flag parsing through to key derivation.
+type argonParams struct {
+	time      uint32
+	memoryKiB uint32
+	threads   byte
 }
 
 func main() {
 	// Define the command line flags
-	key := flag.String("key", "", "the encryption key")
 	encrypt := flag.Bool("encrypt", false, "encrypt a file or directory")
 	decrypt := flag.Bool("decrypt", false, "decrypt a file or directory")
 	deleteOriginal := flag.Bool("d", true, "delete the original file after encryption")
+	promptPassphrase := flag.Bool("P", false, "prompt for the passphrase interactively instead of reading PASSPHRASE")
+	argonTime := flag.Uint("time", defaultArgonTime, "Argon2id time cost (iterations)")
+	argonMemory := flag.Uint("memory", defaultArgonMemory/1024, "Argon2id memory cost in MiB")
+	argonThreads := flag.Uint("threads", defaultArgonThreads, "Argon2id parallelism")
+	reedSolomon := flag.Bool("reedsolo", false, "interleave Reed-Solomon parity so the file survives limited bit rot")
+	shred := flag.Bool("shred", false, "securely overwrite the original file before deleting it (implies -d)")
+	shredPasses := flag.Int("shred-passes", defaultShredPasses, "number of random-data overwrite passes for -shred")
+	format := flag.String("format", formatNative, "file format to write on encrypt: native or age")
+	identityFile := flag.String("identity", "", "age identity file to try on decrypt (age format only)")
+	encryptNames := flag.Bool("encrypt-names", false, "also encrypt file and directory names (directories only)")
+	verify := flag.Bool("verify", false, "authenticate a file or directory's ciphertext without writing out plaintext")
+	var recipients recipientFlags
+	flag.Var(&recipients, "recipient", "age recipient (X25519 public key); repeatable (age format only)")
 	flag.Parse()
 
-	// Check if the key was provided
-	if *key == "" {
-		fmt.Println("Error: encryption key must be provided")
+	modesSelected := 0
+	for _, selected := range []bool{*encrypt, *decrypt, *verify} {
+		if selected {
+			modesSelected++
+		}
+	}
+	if modesSelected == 0 {
+		fmt.Println("Error: one of -encrypt, -decrypt, or -verify must be specified")
+		os.Exit(1)
+	}
+	if modesSelected > 1 {
+		fmt.Println("Error: only one of -encrypt, -decrypt, or -verify may be specified")
 		os.Exit(1)
 	}
 
-	// Pad or trim the key to a valid length for AES
-	PadKey(key)
-	fmt.Printf("Using key: %s\n", *key)
-
-	// Check if either encrypt or decrypt was specified
-	if !*encrypt && !*decrypt {
-		fmt.Println("Error: either -encrypt or -decrypt must be specified")
+	if *format != formatNative && *format != formatAge {
+		fmt.Println("Error: -format must be \"native\" or \"age\"")
 		os.Exit(1)
 	}
 
-	// Check if both encrypt and decrypt were specified
-	if *encrypt && *decrypt {
-		fmt.Println("Error: cannot specify both -encrypt and -decrypt")
+	passphrase, err := readPassphrase(*promptPassphrase)
+	if err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
+	opts := processOpts{
+		argon: argonParams{
+			time:      uint32(*argonTime),
+			memoryKiB: uint32(*argonMemory) * 1024,
+			threads:   byte(*argonThreads),
+		},
+		deleteOrig:   *deleteOriginal || *shred,
+		shred:        *shred,
+		shredPasses:  *shredPasses,
+		reedSolomon:  *reedSolomon,
+		format:       *format,
+		recipients:   recipients,
+		identityFile: *identityFile,
+		encryptNames: *encryptNames,
+	}
+
 	// Get the path to the file or directory
 	path := flag.Arg(0)
 	if path == "" {
@@ -207,26 +416,34 @@ func main() {
 		}
 
 		if fileInfo.IsDir() {
-			ProcessDirectory([]byte(*key), true, *deleteOriginal, path)
+			if err := ProcessDirectory(passphrase, opts, true, path); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		} else {
 			var wg sync.WaitGroup
 			wg.Add(1)
-			go ProcessFile([]byte(*key), true, *deleteOriginal, path, &wg)
+			go ProcessFile(passphrase, opts, true, path, &wg)
 			wg.Wait()
 		}
-	} else if *decrypt {
+	} else {
 		fileInfo, err := os.Stat(path)
 		if err != nil {
 			fmt.Println("Error stating file or directory:", err)
 			os.Exit(1)
 		}
 
+		opts.deleteOrig = false
+		opts.verify = *verify
 		if fileInfo.IsDir() {
-			ProcessDirectory([]byte(*key), false, false, path)
+			if err := ProcessDirectory(passphrase, opts, false, path); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		} else {
 			var wg sync.WaitGroup
 			wg.Add(1)
-			go ProcessFile([]byte(*key), false, false, path, &wg)
+			go ProcessFile(passphrase, opts, false, path, &wg)
 			wg.Wait()
 		}
 	}
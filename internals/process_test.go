@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testArgon keeps Argon2id cheap enough for tests to run fast; production
+// code always uses the flag-provided defaultArgon* costs instead.
+var testArgon = argonParams{time: 1, memoryKiB: 8 * 1024, threads: 1}
+
+func TestProcessDirectoryEncryptVerifyRoundTrip(t *testing.T) {
+	for _, encryptNames := range []bool{false, true} {
+		t.Run(map[bool]string{false: "plain names", true: "encrypt names"}[encryptNames], func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello, world"), 0600); err != nil {
+				t.Fatalf("writing test file: %v", err)
+			}
+			if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+				t.Fatalf("creating subdirectory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "sub", "nested.txt"), []byte("nested contents"), 0600); err != nil {
+				t.Fatalf("writing nested test file: %v", err)
+			}
+
+			passphrase := "correct horse battery staple"
+			encryptOpts := processOpts{argon: testArgon, deleteOrig: true, encryptNames: encryptNames}
+			if err := ProcessDirectory(passphrase, encryptOpts, true, dir); err != nil {
+				t.Fatalf("ProcessDirectory(encrypt): %v", err)
+			}
+
+			// -verify should report success, both with a plain trailing
+			// slash and without one.
+			for _, path := range []string{dir, dir + string(os.PathSeparator)} {
+				verifyOpts := processOpts{argon: testArgon, verify: true}
+				if err := ProcessDirectory(passphrase, verifyOpts, false, path); err != nil {
+					t.Fatalf("ProcessDirectory(verify, path=%q): %v", path, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const defaultShredPasses = 1
+
+// secureDelete overwrites path's current contents with `passes` rounds of
+// cryptographically random data, syncing between each, then truncates it,
+// renames it to something unrelated to the original name, and finally
+// unlinks it, matching the approach fenc's SecureDelete uses. It refuses
+// outright on filesystems where overwriting in place is known to be
+// ineffective (see isCOWFilesystem), falling back to a plain remove and a
+// warning instead of silently doing nothing useful.
+func secureDelete(path string, passes int) error {
+	if cow, fsName, err := isCOWFilesystem(path); err != nil {
+		fmt.Println("Warning: could not determine filesystem type for", path+":", err)
+	} else if cow {
+		fmt.Printf("Warning: %s is on a copy-on-write filesystem (%s); overwriting in place will not reliably erase its old contents, deleting without shredding\n", path, fsName)
+		return os.Remove(path)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("opening file for shredding: %w", err)
+	}
+
+	if _, err := overwritePasses(f, passes); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return fmt.Errorf("truncating shredded file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing shredded file: %w", err)
+	}
+
+	randomPath, err := randomSiblingPath(path)
+	if err != nil {
+		return fmt.Errorf("choosing random name for shredded file: %w", err)
+	}
+	if err := os.Rename(path, randomPath); err != nil {
+		return fmt.Errorf("renaming shredded file: %w", err)
+	}
+
+	return os.Remove(randomPath)
+}
+
+// overwritePasses seeks to the start of f and writes its current length
+// worth of random bytes, syncing to disk between each of the given number
+// of passes.
+func overwritePasses(f *os.File, passes int) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stating file for shredding: %w", err)
+	}
+	size := info.Size()
+
+	for pass := 0; pass < passes; pass++ {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("seeking for shred pass %d: %w", pass, err)
+		}
+		if _, err := io.CopyN(f, rand.Reader, size); err != nil {
+			return 0, fmt.Errorf("overwriting for shred pass %d: %w", pass, err)
+		}
+		if err := f.Sync(); err != nil {
+			return 0, fmt.Errorf("syncing shred pass %d: %w", pass, err)
+		}
+	}
+	return size, nil
+}
+
+// randomSiblingPath picks an unpredictable filename in the same directory
+// as path, so the rename before unlinking doesn't leave the original name
+// (which may itself be meaningful) in any directory entry history.
+func randomSiblingPath(path string) (string, error) {
+	var suffix [16]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%x", suffix)), nil
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// Well-known filesystem magic numbers returned by statfs(2) for filesystems
+// where shredding in place is known not to work: both btrfs and ZFS may
+// write the "overwritten" blocks elsewhere instead of in place, copy-on-write
+// being the whole point.
+const (
+	btrfsSuperMagic = 0x9123683e
+	zfsSuperMagic   = 0x2fc12fc1
+)
+
+// isCOWFilesystem reports whether path lives on a copy-on-write filesystem
+// where secureDelete's in-place overwrite would not reliably erase the
+// original bytes.
+func isCOWFilesystem(path string) (bool, string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return false, "", err
+	}
+
+	switch stat.Type {
+	case btrfsSuperMagic:
+		return true, "btrfs", nil
+	case zfsSuperMagic:
+		return true, "zfs", nil
+	default:
+		return false, "", nil
+	}
+}
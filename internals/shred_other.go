@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+// isCOWFilesystem's copy-on-write detection is Linux-specific (it calls
+// statfs(2) through golang.org/x/sys/unix); elsewhere we have no cheap way
+// to tell, so shredding proceeds without the warning.
+func isCOWFilesystem(path string) (bool, string, error) {
+	return false, "", nil
+}
@@ -0,0 +1,230 @@
+package streamcrypt
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// StreamOpts configures optional behaviour of EncryptStream/DecryptStream
+// beyond the base AEAD chunk framing.
+type StreamOpts struct {
+	// ReedSolomon interleaves Reed-Solomon parity into the ciphertext (see
+	// rsWriter/rsReader below) so the file keeps decrypting after limited
+	// corruption of the bytes on disk.
+	ReedSolomon bool
+}
+
+const (
+	// rsPayloadSize is how many real bytes of the underlying stream each RS
+	// block carries. The 128th data byte is reserved as a control byte (see
+	// rsWriter), and 8 parity bytes are appended, for a coded block size of
+	// 136 bytes - following Picocrypt's RS(128,136) framing.
+	rsPayloadSize     = 127
+	rsBlockDataSize   = rsPayloadSize + 1
+	rsBlockParitySize = 8
+	rsBlockCodedSize  = rsBlockDataSize + rsBlockParitySize
+
+	// HeaderParitySize is the parity byte count used to protect the file
+	// header. It matches the body blocks' parity count, but is applied over
+	// the header's much shorter field block (a "shortened" RS code, in
+	// Picocrypt's terminology).
+	HeaderParitySize = 8
+)
+
+// EncodeRS splits data into one-byte shards and appends paritySize parity
+// shards computed over them, returning the concatenated coded block. It is
+// exported so callers outside this package (the file header, in
+// particular) can apply the same byte-level RS code to a single
+// fixed-size block without going through the chunk-stream framing below.
+func EncodeRS(data []byte, paritySize int) ([]byte, error) {
+	enc, err := reedsolomon.New(len(data), paritySize)
+	if err != nil {
+		return nil, fmt.Errorf("creating RS encoder: %w", err)
+	}
+	return rsEncodeBlock(enc, data, paritySize)
+}
+
+// DecodeRS recovers the dataSize data bytes from a coded block produced by
+// EncodeRS, tolerating up to a single corrupted byte. ok reports whether a
+// consistent block was found; callers should warn and fall back to the
+// raw, unverified bytes otherwise.
+func DecodeRS(coded []byte, dataSize, paritySize int) (data []byte, ok bool, err error) {
+	enc, err := reedsolomon.New(dataSize, paritySize)
+	if err != nil {
+		return nil, false, fmt.Errorf("creating RS encoder: %w", err)
+	}
+	data, ok = rsDecodeBlock(enc, coded, dataSize)
+	return data, ok, nil
+}
+
+func rsEncodeBlock(enc reedsolomon.Encoder, data []byte, paritySize int) ([]byte, error) {
+	shards := make([][]byte, len(data)+paritySize)
+	for i := range data {
+		shards[i] = data[i : i+1]
+	}
+	for i := len(data); i < len(shards); i++ {
+		shards[i] = make([]byte, 1)
+	}
+	if err := enc.Encode(shards); err != nil {
+		return nil, fmt.Errorf("encoding RS block: %w", err)
+	}
+
+	coded := make([]byte, len(shards))
+	for i, s := range shards {
+		coded[i] = s[0]
+	}
+	return coded, nil
+}
+
+// rsDecodeBlock recovers dataSize data bytes from a coded block. The
+// erasure decoder in klauspost/reedsolomon needs to be told which shard is
+// bad, and a corrupted byte gives no such hint, so we first verify the
+// block as received and, failing that, brute-force each shard position as
+// the erasure in turn until a reconstruction passes verification. This
+// bounds the search to len(coded) attempts, which is cheap at this block
+// size and repairs any single-byte error, matching what RS(128,136) can
+// guarantee.
+func rsDecodeBlock(enc reedsolomon.Encoder, coded []byte, dataSize int) (data []byte, ok bool) {
+	shards := make([][]byte, len(coded))
+	for i := range coded {
+		shards[i] = []byte{coded[i]}
+	}
+
+	if valid, err := enc.Verify(shards); err == nil && valid {
+		return joinShards(shards, dataSize), true
+	}
+
+	for bad := range shards {
+		trial := make([][]byte, len(shards))
+		copy(trial, shards)
+		trial[bad] = nil
+		if err := enc.Reconstruct(trial); err != nil {
+			continue
+		}
+		if valid, err := enc.Verify(trial); err == nil && valid {
+			return joinShards(trial, dataSize), true
+		}
+	}
+
+	return joinShards(shards, dataSize), false
+}
+
+func joinShards(shards [][]byte, dataSize int) []byte {
+	data := make([]byte, dataSize)
+	for i := 0; i < dataSize; i++ {
+		data[i] = shards[i][0]
+	}
+	return data
+}
+
+// rsWriter interleaves Reed-Solomon parity into everything written to it,
+// framing the byte stream into rsPayloadSize chunks so an io.Writer wrapped
+// in one is transparent to its caller aside from needing a final Close to
+// flush any partial last block.
+type rsWriter struct {
+	enc reedsolomon.Encoder
+	out io.Writer
+	buf []byte
+}
+
+func newRSWriter(out io.Writer) (*rsWriter, error) {
+	enc, err := reedsolomon.New(rsBlockDataSize, rsBlockParitySize)
+	if err != nil {
+		return nil, fmt.Errorf("creating RS encoder: %w", err)
+	}
+	return &rsWriter{enc: enc, out: out}, nil
+}
+
+func (w *rsWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= rsPayloadSize {
+		if err := w.flush(w.buf[:rsPayloadSize], 0); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[rsPayloadSize:]
+	}
+	return written, nil
+}
+
+// Close flushes any buffered partial block, recording how many trailing
+// padding bytes it contains in the block's control byte so the reader can
+// trim them back off.
+func (w *rsWriter) Close() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	pad := rsPayloadSize - len(w.buf)
+	payload := make([]byte, rsPayloadSize)
+	copy(payload, w.buf)
+	w.buf = nil
+	return w.flush(payload, byte(pad))
+}
+
+func (w *rsWriter) flush(payload []byte, control byte) error {
+	block := make([]byte, rsBlockDataSize)
+	copy(block, payload)
+	block[rsPayloadSize] = control
+
+	coded, err := rsEncodeBlock(w.enc, block, rsBlockParitySize)
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(coded)
+	return err
+}
+
+// rsReader is the inverse of rsWriter: it reads coded blocks from an
+// underlying reader, repairs a single corrupted byte per block when
+// possible, and presents the original byte stream to callers through Read.
+type rsReader struct {
+	enc     reedsolomon.Encoder
+	in      io.Reader
+	pending []byte
+}
+
+func newRSReader(in io.Reader) (*rsReader, error) {
+	enc, err := reedsolomon.New(rsBlockDataSize, rsBlockParitySize)
+	if err != nil {
+		return nil, fmt.Errorf("creating RS encoder: %w", err)
+	}
+	return &rsReader{enc: enc, in: in}, nil
+}
+
+func (r *rsReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *rsReader) fill() error {
+	coded := make([]byte, rsBlockCodedSize)
+	n, err := io.ReadFull(r.in, coded)
+	if err != nil {
+		if err == io.EOF && n == 0 {
+			return io.EOF
+		}
+		return fmt.Errorf("reading RS block: %w", err)
+	}
+
+	block, ok := rsDecodeBlock(r.enc, coded, rsBlockDataSize)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "warning: RS block failed to verify, continuing with unrepaired bytes")
+	}
+
+	payload := block[:rsPayloadSize]
+	control := block[rsPayloadSize]
+	if control > 0 && int(control) <= rsPayloadSize {
+		payload = payload[:rsPayloadSize-int(control)]
+	}
+	r.pending = payload
+	return nil
+}
@@ -0,0 +1,88 @@
+package streamcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncodeDecodeRSRoundTrip(t *testing.T) {
+	data := make([]byte, rsBlockDataSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("generating test data: %v", err)
+	}
+
+	coded, err := EncodeRS(data, rsBlockParitySize)
+	if err != nil {
+		t.Fatalf("EncodeRS: %v", err)
+	}
+	if len(coded) != rsBlockCodedSize {
+		t.Fatalf("coded block size = %d, want %d", len(coded), rsBlockCodedSize)
+	}
+
+	decoded, ok, err := DecodeRS(coded, len(data), rsBlockParitySize)
+	if err != nil {
+		t.Fatalf("DecodeRS: %v", err)
+	}
+	if !ok {
+		t.Fatal("DecodeRS reported an unverified block for untouched input")
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded data does not match original")
+	}
+}
+
+func TestDecodeRSRepairsSingleByteCorruption(t *testing.T) {
+	data := make([]byte, rsBlockDataSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("generating test data: %v", err)
+	}
+
+	coded, err := EncodeRS(data, rsBlockParitySize)
+	if err != nil {
+		t.Fatalf("EncodeRS: %v", err)
+	}
+
+	for _, pos := range []int{0, len(coded) / 2, len(coded) - 1} {
+		corrupted := make([]byte, len(coded))
+		copy(corrupted, coded)
+		corrupted[pos] ^= 0xFF
+
+		decoded, ok, err := DecodeRS(corrupted, len(data), rsBlockParitySize)
+		if err != nil {
+			t.Fatalf("DecodeRS with corruption at byte %d: %v", pos, err)
+		}
+		if !ok {
+			t.Fatalf("DecodeRS did not repair single-byte corruption at byte %d", pos)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("repaired data at byte %d does not match original", pos)
+		}
+	}
+}
+
+func TestDecodeRSReportsUnrepairableBlock(t *testing.T) {
+	data := make([]byte, rsBlockDataSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("generating test data: %v", err)
+	}
+
+	coded, err := EncodeRS(data, rsBlockParitySize)
+	if err != nil {
+		t.Fatalf("EncodeRS: %v", err)
+	}
+
+	// Corrupt more bytes than the parity can repair so DecodeRS must report
+	// the block as unverified rather than silently returning wrong data.
+	for i := 0; i < rsBlockParitySize; i++ {
+		coded[i] ^= 0xFF
+	}
+
+	_, ok, err := DecodeRS(coded, len(data), rsBlockParitySize)
+	if err != nil {
+		t.Fatalf("DecodeRS: %v", err)
+	}
+	if ok {
+		t.Fatal("DecodeRS reported a heavily corrupted block as verified")
+	}
+}
@@ -0,0 +1,168 @@
+// Package streamcrypt implements constant-memory AEAD encryption of
+// arbitrarily large streams by framing the plaintext into fixed-size chunks,
+// following the same shape as rclone crypt and age: each chunk is sealed
+// independently with AES-256-GCM so a multi-GB file never has to be held in
+// memory at once.
+package streamcrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// ChunkSize is the amount of plaintext sealed into each frame.
+	ChunkSize = 64 * 1024
+
+	baseNonceSize = 8
+	nonceSize     = 12
+	tagSize       = 16
+
+	// finalBit is set in the high bit of the per-chunk counter to mark the
+	// last frame of a stream, so truncation can be detected on decrypt.
+	finalBit = uint32(1) << 31
+)
+
+// frameNonce builds the 12-byte GCM nonce for a chunk from the stream's
+// random base nonce and a little-endian chunk counter.
+func frameNonce(base [baseNonceSize]byte, counter uint32) [nonceSize]byte {
+	var nonce [nonceSize]byte
+	copy(nonce[:baseNonceSize], base[:])
+	binary.LittleEndian.PutUint32(nonce[baseNonceSize:], counter)
+	return nonce
+}
+
+// EncryptStream reads plaintext from in, seals it in ChunkSize frames, and
+// writes `[chunk-nonce (12B)][ciphertext+tag]` frames to out. Memory usage is
+// independent of the size of in. When opts.ReedSolomon is set, the frames
+// are interleaved with Reed-Solomon parity (see rs.go) before reaching out.
+func EncryptStream(key []byte, in io.Reader, out io.Writer, opts StreamOpts) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("creating cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("creating GCM: %w", err)
+	}
+
+	var base [baseNonceSize]byte
+	if _, err := rand.Read(base[:]); err != nil {
+		return fmt.Errorf("generating base nonce: %w", err)
+	}
+
+	dst := out
+	var rsOut *rsWriter
+	if opts.ReedSolomon {
+		rsOut, err = newRSWriter(out)
+		if err != nil {
+			return err
+		}
+		dst = rsOut
+	}
+
+	br := bufio.NewReaderSize(in, ChunkSize)
+	buf := make([]byte, ChunkSize)
+
+	for counter := uint32(0); ; counter++ {
+		n, readErr := io.ReadFull(br, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("reading plaintext chunk: %w", readErr)
+		}
+
+		_, peekErr := br.Peek(1)
+		final := peekErr != nil
+
+		frameCounter := counter
+		if final {
+			frameCounter |= finalBit
+		}
+		nonce := frameNonce(base, frameCounter)
+
+		ciphertext := gcm.Seal(nil, nonce[:], buf[:n], nil)
+		if _, err := dst.Write(nonce[:]); err != nil {
+			return fmt.Errorf("writing chunk nonce: %w", err)
+		}
+		if _, err := dst.Write(ciphertext); err != nil {
+			return fmt.Errorf("writing chunk ciphertext: %w", err)
+		}
+
+		if final {
+			if rsOut != nil {
+				return rsOut.Close()
+			}
+			return nil
+		}
+	}
+}
+
+// DecryptStream reads frames written by EncryptStream from in, authenticates
+// and decrypts each one, and writes the recovered plaintext to out. It
+// returns an error if the final frame's marker is never seen, so silent
+// truncation of the ciphertext is detected rather than producing short
+// plaintext. When opts.ReedSolomon is set, in is expected to carry the
+// Reed-Solomon framing EncryptStream adds, and isolated byte corruption is
+// repaired transparently before the AEAD frames are parsed.
+func DecryptStream(key []byte, in io.Reader, out io.Writer, opts StreamOpts) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("creating cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("creating GCM: %w", err)
+	}
+
+	src := in
+	if opts.ReedSolomon {
+		rsIn, err := newRSReader(in)
+		if err != nil {
+			return err
+		}
+		src = rsIn
+	}
+
+	var nonce [nonceSize]byte
+	ciphertext := make([]byte, ChunkSize+tagSize)
+
+	for chunkIndex := 0; ; chunkIndex++ {
+		if _, err := io.ReadFull(src, nonce[:]); err != nil {
+			if err == io.EOF && chunkIndex > 0 {
+				return fmt.Errorf("truncated stream: final chunk marker never seen")
+			}
+			return fmt.Errorf("reading chunk %d nonce: %w", chunkIndex, err)
+		}
+
+		n, readErr := io.ReadFull(src, ciphertext)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("reading chunk %d ciphertext: %w", chunkIndex, readErr)
+		}
+		if n < tagSize {
+			return fmt.Errorf("truncated stream: chunk %d shorter than GCM tag", chunkIndex)
+		}
+
+		counter := binary.LittleEndian.Uint32(nonce[baseNonceSize:])
+		final := counter&finalBit != 0
+
+		if readErr != nil && !final {
+			return fmt.Errorf("truncated stream: final chunk marker never seen")
+		}
+
+		plaintext, err := gcm.Open(nil, nonce[:], ciphertext[:n], nil)
+		if err != nil {
+			return fmt.Errorf("authenticating chunk %d: %w", chunkIndex, err)
+		}
+		if _, err := out.Write(plaintext); err != nil {
+			return fmt.Errorf("writing plaintext chunk %d: %w", chunkIndex, err)
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
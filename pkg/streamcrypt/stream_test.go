@@ -0,0 +1,95 @@
+package streamcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	return key
+}
+
+func TestEncryptDecryptStreamRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"partial chunk", ChunkSize/2 + 1},
+		{"exact chunk", ChunkSize},
+		{"multiple chunks with remainder", ChunkSize*3 + 17},
+		{"exact multiple of chunks", ChunkSize * 2},
+	}
+
+	for _, opts := range []StreamOpts{{ReedSolomon: false}, {ReedSolomon: true}} {
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				key := testKey(t)
+				plaintext := make([]byte, tc.size)
+				if _, err := rand.Read(plaintext); err != nil {
+					t.Fatalf("generating plaintext: %v", err)
+				}
+
+				var ciphertext bytes.Buffer
+				if err := EncryptStream(key, bytes.NewReader(plaintext), &ciphertext, opts); err != nil {
+					t.Fatalf("EncryptStream: %v", err)
+				}
+
+				var decrypted bytes.Buffer
+				if err := DecryptStream(key, bytes.NewReader(ciphertext.Bytes()), &decrypted, opts); err != nil {
+					t.Fatalf("DecryptStream: %v", err)
+				}
+
+				if !bytes.Equal(decrypted.Bytes(), plaintext) {
+					t.Fatalf("round trip mismatch: got %d bytes, want %d bytes", decrypted.Len(), len(plaintext))
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptStreamDetectsTruncation(t *testing.T) {
+	key := testKey(t)
+	plaintext := make([]byte, ChunkSize*2+100)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("generating plaintext: %v", err)
+	}
+
+	var ciphertext bytes.Buffer
+	if err := EncryptStream(key, bytes.NewReader(plaintext), &ciphertext, StreamOpts{}); err != nil {
+		t.Fatalf("EncryptStream: %v", err)
+	}
+
+	truncated := ciphertext.Bytes()[:ciphertext.Len()-10]
+	if err := DecryptStream(key, bytes.NewReader(truncated), &bytes.Buffer{}, StreamOpts{}); err == nil {
+		t.Fatal("DecryptStream did not detect a truncated final chunk")
+	}
+}
+
+func TestDecryptStreamRepairsBitRotWithReedSolomon(t *testing.T) {
+	key := testKey(t)
+	plaintext := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog"), 100)
+
+	var ciphertext bytes.Buffer
+	opts := StreamOpts{ReedSolomon: true}
+	if err := EncryptStream(key, bytes.NewReader(plaintext), &ciphertext, opts); err != nil {
+		t.Fatalf("EncryptStream: %v", err)
+	}
+
+	corrupted := ciphertext.Bytes()
+	corrupted[rsBlockCodedSize/2] ^= 0xFF
+
+	var decrypted bytes.Buffer
+	if err := DecryptStream(key, bytes.NewReader(corrupted), &decrypted, opts); err != nil {
+		t.Fatalf("DecryptStream did not repair single-byte corruption: %v", err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Fatal("repaired plaintext does not match original")
+	}
+}